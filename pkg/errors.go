@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+)
+
+// Типизированные ошибки хранилища. Методы Storage возвращают их вместо
+// "сырых" ошибок pgx, чтобы вызывающий код мог различать причины отказа
+// через errors.Is, не разбирая коды Postgres самостоятельно.
+var (
+	ErrNotFound       = errors.New("storage: not found")
+	ErrConflict       = errors.New("storage: unique constraint violation")
+	ErrForeignKey     = errors.New("storage: foreign key violation")
+	ErrCheckViolation = errors.New("storage: check constraint violation")
+	ErrSerialization  = errors.New("storage: serialization failure")
+	ErrConnFailure    = errors.New("storage: connection failure")
+)
+
+// HandlePgErr приводит ошибку pgx/Postgres к одной из типизированных
+// ошибок хранилища. Если err не распознан как специфичная ошибка
+// Postgres, он возвращается без изменений.
+func HandlePgErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			return ErrConflict
+		case pgerrcode.ForeignKeyViolation:
+			return ErrForeignKey
+		case pgerrcode.CheckViolation:
+			return ErrCheckViolation
+		case pgerrcode.SerializationFailure:
+			return ErrSerialization
+		}
+		return err
+	}
+
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return ErrConnFailure
+	}
+
+	return err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestHandlePgErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "no rows",
+			err:  pgx.ErrNoRows,
+			want: ErrNotFound,
+		},
+		{
+			name: "unique violation",
+			err:  &pgconn.PgError{Code: pgerrcode.UniqueViolation},
+			want: ErrConflict,
+		},
+		{
+			name: "foreign key violation",
+			err:  &pgconn.PgError{Code: pgerrcode.ForeignKeyViolation},
+			want: ErrForeignKey,
+		},
+		{
+			name: "check violation",
+			err:  &pgconn.PgError{Code: pgerrcode.CheckViolation},
+			want: ErrCheckViolation,
+		},
+		{
+			name: "serialization failure",
+			err:  &pgconn.PgError{Code: pgerrcode.SerializationFailure},
+			want: ErrSerialization,
+		},
+		{
+			name: "connection failure",
+			err:  &pgconn.ConnectError{},
+			want: ErrConnFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HandlePgErr(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("HandlePgErr(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlePgErr_nil(t *testing.T) {
+	if err := HandlePgErr(nil); err != nil {
+		t.Errorf("HandlePgErr(nil) = %v, want nil", err)
+	}
+}
+
+func TestHandlePgErr_unmappedCode(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: pgerrcode.SyntaxError}
+	got := HandlePgErr(pgErr)
+	if !errors.Is(got, pgErr) {
+		t.Errorf("HandlePgErr(%v) = %v, want the error unchanged", pgErr, got)
+	}
+}
+
+func TestHandlePgErr_unrecognized(t *testing.T) {
+	plain := errors.New("boom")
+	got := HandlePgErr(plain)
+	if !errors.Is(got, plain) {
+		t.Errorf("HandlePgErr(%v) = %v, want the error unchanged", plain, got)
+	}
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TaskFilter описывает условия отбора задач для FindTasks/CountTasks.
+// Нулевое значение поля-указателя означает "условие не задано".
+type TaskFilter struct {
+	AuthorID     *int
+	AssignedID   *int
+	Label        *string
+	OpenedAfter  *int64
+	OpenedBefore *int64
+	ClosedOnly   bool
+	OpenOnly     bool
+
+	// Limit и Offset задают постраничную выборку. Limit <= 0 означает
+	// "без ограничения".
+	Limit  int
+	Offset int
+
+	// OrderBy — имя столбца сортировки, опционально с суффиксом " desc"
+	// (например "opened desc"). Допустимые имена перечислены в
+	// taskOrderColumns; всё остальное отклоняется, чтобы в запрос
+	// не попадали произвольные идентификаторы.
+	OrderBy string
+}
+
+// taskOrderColumns — разрешённые для OrderBy столбцы.
+var taskOrderColumns = map[string]string{
+	"id":          "t.id",
+	"opened":      "t.opened",
+	"closed":      "t.closed",
+	"author_id":   "t.author_id",
+	"assigned_id": "t.assigned_id",
+	"title":       "t.title",
+}
+
+// resolveOrderBy проверяет OrderBy по белому списку и возвращает готовый
+// фрагмент ORDER BY. При пустом значении используется сортировка по
+// умолчанию — t.id; недопустимое значение приводит к ошибке.
+func (f TaskFilter) resolveOrderBy() (string, error) {
+	if f.OrderBy == "" {
+		return "t.id", nil
+	}
+
+	column, desc := strings.TrimSuffix(f.OrderBy, " desc"), strings.HasSuffix(f.OrderBy, " desc")
+	if !desc {
+		column = strings.TrimSuffix(column, " asc")
+	}
+
+	col, ok := taskOrderColumns[column]
+	if !ok {
+		return "", fmt.Errorf("storage: invalid OrderBy column %q", f.OrderBy)
+	}
+	if desc {
+		return col + " DESC", nil
+	}
+	return col, nil
+}
+
+// whereClause строит условие WHERE и список аргументов под него,
+// последовательно нумеруя параметры начиная с $1.
+func (f TaskFilter) whereClause() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.AuthorID != nil {
+		add("t.author_id = $%d", *f.AuthorID)
+	}
+	if f.AssignedID != nil {
+		add("t.assigned_id = $%d", *f.AssignedID)
+	}
+	if f.Label != nil {
+		add(`t.id IN (
+			SELECT tl.task_id FROM task_labels tl
+			INNER JOIN labels l ON l.id = tl.label_id
+			WHERE l.label = $%d
+		)`, *f.Label)
+	}
+	if f.OpenedAfter != nil {
+		add("t.opened >= $%d", *f.OpenedAfter)
+	}
+	if f.OpenedBefore != nil {
+		add("t.opened <= $%d", *f.OpenedBefore)
+	}
+	if f.ClosedOnly {
+		conds = append(conds, "t.closed != 0")
+	}
+	if f.OpenOnly {
+		conds = append(conds, "t.closed = 0")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// applyPagination добавляет к query условия LIMIT/OFFSET (и
+// соответствующие позиционные аргументы, продолжая нумерацию с
+// len(args)) согласно f.Limit и f.Offset. Неположительное значение
+// пропускает соответствующее условие.
+func (f TaskFilter) applyPagination(query string, args []interface{}) (string, []interface{}) {
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	return query, args
+}
+
+// FindTasks возвращает задачи, удовлетворяющие фильтру, вместе с общим
+// числом подходящих под него задач (без учёта Limit/Offset) — этого
+// достаточно, чтобы отрисовать постраничную навигацию. Заменяет
+// устаревший Tasks, принимавший только пару (id, authorID).
+func (s *Storage) FindTasks(ctx context.Context, filter TaskFilter) ([]Task, int, error) {
+	orderBy, err := filter.resolveOrderBy()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	where, args := filter.whereClause()
+
+	total, err := s.countTasks(ctx, where, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	query := taskSelectWithLabels + " " + where + " GROUP BY t.id ORDER BY " + orderBy
+	query, args = filter.applyPagination(query, args)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTaskWithLabels(rows)
+		if err != nil {
+			return nil, 0, HandlePgErr(err)
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, total, HandlePgErr(rows.Err())
+}
+
+// CountTasks возвращает количество задач, удовлетворяющих фильтру, не
+// выбирая сами строки — дешевле, чем FindTasks, когда нужен только
+// общий счётчик.
+func (s *Storage) CountTasks(ctx context.Context, filter TaskFilter) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	where, args := filter.whereClause()
+	return s.countTasks(ctx, where, args)
+}
+
+func (s *Storage) countTasks(ctx context.Context, where string, args []interface{}) (int, error) {
+	query := "SELECT count(*) FROM tasks t " + where
+	var total int
+	err := s.db.QueryRow(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return 0, HandlePgErr(err)
+	}
+	return total, nil
+}
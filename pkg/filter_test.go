@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaskFilter_whereClause(t *testing.T) {
+	authorID := 7
+	label := "bug"
+
+	where, args := TaskFilter{AuthorID: &authorID, Label: &label, OpenOnly: true}.whereClause()
+
+	if !strings.HasPrefix(where, "WHERE t.author_id = $1 AND t.id IN (") {
+		t.Errorf("whereClause() where = %q, want it to start with the author_id and label conditions in order", where)
+	}
+	if !strings.Contains(where, "l.label = $2") {
+		t.Errorf("whereClause() where = %q, want the label placeholder numbered $2", where)
+	}
+	if !strings.HasSuffix(where, "AND t.closed = 0") {
+		t.Errorf("whereClause() where = %q, want it to end with the open-only condition", where)
+	}
+	wantArgs := []interface{}{authorID, label}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("whereClause() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestTaskFilter_whereClause_empty(t *testing.T) {
+	where, args := TaskFilter{}.whereClause()
+	if where != "" {
+		t.Errorf("whereClause() where = %q, want empty", where)
+	}
+	if len(args) != 0 {
+		t.Errorf("whereClause() args = %v, want empty", args)
+	}
+}
+
+func TestTaskFilter_applyPagination(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      TaskFilter
+		initialArgs []interface{}
+		wantQuery   string
+		wantArgs    []interface{}
+	}{
+		{
+			name:      "no limit or offset",
+			filter:    TaskFilter{},
+			wantQuery: "SELECT 1",
+			wantArgs:  nil,
+		},
+		{
+			name:      "limit only",
+			filter:    TaskFilter{Limit: 10},
+			wantQuery: "SELECT 1 LIMIT $1",
+			wantArgs:  []interface{}{10},
+		},
+		{
+			name:      "offset only",
+			filter:    TaskFilter{Offset: 20},
+			wantQuery: "SELECT 1 OFFSET $1",
+			wantArgs:  []interface{}{20},
+		},
+		{
+			name:        "limit and offset continue existing args numbering",
+			filter:      TaskFilter{Limit: 10, Offset: 20},
+			initialArgs: []interface{}{"existing"},
+			wantQuery:   "SELECT 1 LIMIT $2 OFFSET $3",
+			wantArgs:    []interface{}{"existing", 10, 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, gotArgs := tt.filter.applyPagination("SELECT 1", tt.initialArgs)
+			if query != tt.wantQuery {
+				t.Errorf("applyPagination() query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("applyPagination() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestTaskFilter_resolveOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderBy string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", orderBy: "", want: "t.id"},
+		{name: "plain column", orderBy: "opened", want: "t.opened"},
+		{name: "descending", orderBy: "opened desc", want: "t.opened DESC"},
+		{name: "ascending suffix", orderBy: "opened asc", want: "t.opened"},
+		{name: "invalid column", orderBy: "'; DROP TABLE tasks; --", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TaskFilter{OrderBy: tt.orderBy}.resolveOrderBy()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveOrderBy() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveOrderBy() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveOrderBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{name: "no duplicates", in: []string{"bug", "feature"}, want: []string{"bug", "feature"}},
+		{name: "duplicates", in: []string{"bug", "bug", "feature", "bug"}, want: []string{"bug", "feature"}},
+		{name: "empty", in: nil, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distinct(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("distinct(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
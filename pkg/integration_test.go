@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestStorage подключается к Postgres по адресу из переменной
+// окружения TEST_DATABASE_URL. Использующие её тесты пропускаются, если
+// переменная не задана — им нужна настоящая, смигрированная база, а не
+// мок.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	constr := os.Getenv("TEST_DATABASE_URL")
+	if constr == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping test against a real Postgres")
+	}
+
+	s, err := New(context.Background(), constr)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func TestHandlePgErr_realPostgres(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	if _, err := s.GetTaskByID(ctx, -1); err != ErrNotFound {
+		t.Errorf("GetTaskByID(-1) error = %v, want ErrNotFound", err)
+	}
+
+	id, err := s.CreateLabel(ctx, "chunk0-2-dup")
+	if err != nil {
+		t.Fatalf("CreateLabel() error = %v", err)
+	}
+	if _, err := s.CreateLabel(ctx, "chunk0-2-dup"); err != ErrConflict {
+		t.Errorf("CreateLabel(duplicate) error = %v, want ErrConflict", err)
+	}
+
+	if err := s.AttachLabel(ctx, -1, id); !errors.Is(err, ErrForeignKey) {
+		t.Errorf("AttachLabel(missing task) error = %v, want ErrForeignKey", err)
+	}
+}
+
+func TestFindTasks_pagination(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.NewTask(ctx, Task{Title: "chunk0-4 pagination"}); err != nil {
+			t.Fatalf("NewTask() error = %v", err)
+		}
+	}
+
+	page1, total, err := s.FindTasks(ctx, TaskFilter{Limit: 2, Offset: 0, OrderBy: "id"})
+	if err != nil {
+		t.Fatalf("FindTasks() error = %v", err)
+	}
+	if total < 3 {
+		t.Errorf("FindTasks() total = %d, want >= 3", total)
+	}
+	if len(page1) > 2 {
+		t.Errorf("FindTasks() returned %d rows, want <= 2 (Limit)", len(page1))
+	}
+}
+
+func TestTasksByLabels_matchAllDedup(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	taskID, err := s.NewTask(ctx, Task{Title: "chunk0-1 matchAll dedup"})
+	if err != nil {
+		t.Fatalf("NewTask() error = %v", err)
+	}
+	labelID, err := s.CreateLabel(ctx, "bug")
+	if err != nil {
+		t.Fatalf("CreateLabel() error = %v", err)
+	}
+	if err := s.AttachLabel(ctx, taskID, labelID); err != nil {
+		t.Fatalf("AttachLabel() error = %v", err)
+	}
+
+	tasks, err := s.TasksByLabels(ctx, []string{"bug", "bug"}, true)
+	if err != nil {
+		t.Fatalf("TasksByLabels() error = %v", err)
+	}
+
+	found := false
+	for _, task := range tasks {
+		if task.ID == taskID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("TasksByLabels(matchAll, duplicate tags) did not return task %d tagged with a single matching label", taskID)
+	}
+}
+
+func TestAssignTasks_singleTask(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	taskID, err := s.NewTask(ctx, Task{Title: "chunk0-5 retry"})
+	if err != nil {
+		t.Fatalf("NewTask() error = %v", err)
+	}
+
+	if err := s.AssignTasks(ctx, []int{taskID}, 42); err != nil {
+		t.Errorf("AssignTasks() error = %v", err)
+	}
+}
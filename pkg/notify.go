@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TaskChangesDDL — DDL триггера, рассылающего уведомления об изменениях
+// tasks через pg_notify. Применяется отдельно, как обычная миграция;
+// Storage его сам не выполняет.
+const TaskChangesDDL = `
+CREATE OR REPLACE FUNCTION notify_task_changes() RETURNS trigger AS $$
+DECLARE
+	payload json;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', 'delete', 'id', OLD.id, 'author_id', OLD.author_id);
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', 'update', 'id', NEW.id, 'author_id', NEW.author_id);
+	ELSE
+		payload := json_build_object('op', 'insert', 'id', NEW.id, 'author_id', NEW.author_id);
+	END IF;
+
+	PERFORM pg_notify('task_changes', payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_notify_changes ON tasks;
+CREATE TRIGGER tasks_notify_changes
+	AFTER INSERT OR UPDATE OR DELETE ON tasks
+	FOR EACH ROW EXECUTE FUNCTION notify_task_changes();
+`
+
+// TaskEvent — событие об изменении задачи, полученное через канал
+// task_changes.
+type TaskEvent struct {
+	Op       string `json:"op"`
+	ID       int    `json:"id"`
+	AuthorID int    `json:"author_id"`
+}
+
+// SubscribeTaskChanges подписывается на канал task_changes и отдаёт
+// декодированные события в возвращённый канал, пока не отменят ctx.
+// Соединение удерживается отдельно от пула все время подписки, как и
+// требует LISTEN/NOTIFY в pgx, и освобождается по завершении.
+func (s *Storage) SubscribeTaskChanges(ctx context.Context) (<-chan TaskEvent, error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, HandlePgErr(err)
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN task_changes;"); err != nil {
+		conn.Release()
+		return nil, HandlePgErr(err)
+	}
+
+	events := make(chan TaskEvent)
+	go func() {
+		defer conn.Release()
+		defer close(events)
+
+		for {
+			notification, err := conn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return
+			}
+
+			var e TaskEvent
+			if err := json.Unmarshal([]byte(notification.Payload), &e); err != nil {
+				continue
+			}
+
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
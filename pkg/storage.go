@@ -2,27 +2,61 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // Хранилище данных.
 type Storage struct {
-	db *pgxpool.Pool
+	db             *pgxpool.Pool
+	defaultTimeout time.Duration
 }
 
-// Конструктор, принимает строку подключения к БД.
-func New(constr string) (*Storage, error) {
-	db, err := pgxpool.Connect(context.Background(), constr)
+// Option настраивает Storage при создании через New.
+type Option func(*Storage)
+
+// WithDefaultTimeout задаёт таймаут, который применяется к вызовам
+// методов Storage, если переданный в них context.Context не имеет
+// собственного дедлайна.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(s *Storage) {
+		s.defaultTimeout = d
+	}
+}
+
+// Конструктор, принимает контекст (используется при установлении
+// соединения с БД) и строку подключения к БД.
+func New(ctx context.Context, constr string, opts ...Option) (*Storage, error) {
+	db, err := pgxpool.Connect(ctx, constr)
 	if err != nil {
-		return nil, err
+		return nil, HandlePgErr(err)
 	}
 	s := Storage{
 		db: db,
 	}
+	for _, opt := range opts {
+		opt(&s)
+	}
 	return &s, nil
 }
 
+// withTimeout возвращает производный от ctx контекст с дедлайном по
+// умолчанию, если у ctx собственного дедлайна нет и s.defaultTimeout
+// задан. Если условия не выполняются, ctx возвращается как есть и
+// возвращённый cancel — no-op.
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.defaultTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.defaultTimeout)
+}
+
 // Задача.
 type Task struct {
 	ID         int
@@ -32,186 +66,257 @@ type Task struct {
 	AssignedID int
 	Title      string
 	Content    string
+	Labels     []string
+}
+
+// Label — метка, которой можно помечать задачи.
+type Label struct {
+	ID    int
+	Label string
+}
+
+// taskSelectWithLabels — общий фрагмент SELECT для выборки задач вместе
+// со списком меток, агрегированных в массив.
+const taskSelectWithLabels = `
+	SELECT
+		t.id,
+		t.opened,
+		t.closed,
+		t.author_id,
+		t.assigned_id,
+		t.title,
+		t.content,
+		COALESCE(array_agg(l.label) FILTER (WHERE l.label IS NOT NULL), '{}')
+	FROM tasks t
+	LEFT JOIN task_labels tl ON tl.task_id = t.id
+	LEFT JOIN labels l ON l.id = tl.label_id
+`
+
+// scanTaskWithLabels сканирует одну строку, полученную через
+// taskSelectWithLabels, в Task.
+func scanTaskWithLabels(row pgx.Row) (Task, error) {
+	var t Task
+	err := row.Scan(
+		&t.ID,
+		&t.Opened,
+		&t.Closed,
+		&t.AuthorID,
+		&t.AssignedID,
+		&t.Title,
+		&t.Content,
+		&t.Labels,
+	)
+	return t, err
 }
 
 // Tasks возвращает список задач из БД.
-func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT 
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
+//
+// Deprecated: фильтрация через пару (taskID, authorID) с сентинелом 0
+// не расширяема и не поддерживает пагинацию. Используйте FindTasks с
+// TaskFilter.
+func (s *Storage) Tasks(ctx context.Context, taskID, authorID int) ([]Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, taskSelectWithLabels+`
 		WHERE
-			($1 = 0 OR id = $1) AND
-			($2 = 0 OR author_id = $2)
-		ORDER BY id;
+			($1 = 0 OR t.id = $1) AND
+			($2 = 0 OR t.author_id = $2)
+		GROUP BY t.id
+		ORDER BY t.id;
 	`,
 		taskID,
 		authorID,
 	)
 	if err != nil {
-		return nil, err
+		return nil, HandlePgErr(err)
 	}
+	defer rows.Close()
+
 	var tasks []Task
 	// итерирование по результату выполнения запроса
 	// и сканирование каждой строки в переменную
 	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		t, err := scanTaskWithLabels(rows)
 		if err != nil {
-			return nil, err
+			return nil, HandlePgErr(err)
 		}
 		// добавление переменной в массив результатов
 		tasks = append(tasks, t)
 
 	}
 	// ВАЖНО не забыть проверить rows.Err()
-	return tasks, rows.Err()
+	return tasks, HandlePgErr(rows.Err())
 }
 
 // NewTask создаёт новую задачу и возвращает её id.
-func (s *Storage) NewTask(t Task) (int, error) {
+func (s *Storage) NewTask(ctx context.Context, t Task) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	var id int
-	err := s.db.QueryRow(context.Background(), `
+	err := s.db.QueryRow(ctx, `
 		INSERT INTO tasks (title, content)
 		VALUES ($1, $2) RETURNING id;
 		`,
 		t.Title,
 		t.Content,
 	).Scan(&id)
-	return id, err
+	return id, HandlePgErr(err)
 }
 
 // TasksByAuthor возвращает список задач, созданных указанным автором.
-func (s *Storage) TasksByAuthor(authorID int) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
-		WHERE author_id = $1
-		ORDER BY id;
+func (s *Storage) TasksByAuthor(ctx context.Context, authorID int) ([]Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, taskSelectWithLabels+`
+		WHERE t.author_id = $1
+		GROUP BY t.id
+		ORDER BY t.id;
 `, authorID)
 	if err != nil {
-		return nil, err
+		return nil, HandlePgErr(err)
 	}
 	defer rows.Close()
 
 	var tasks []Task
 	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		t, err := scanTaskWithLabels(rows)
 		if err != nil {
-			return nil, err
+			return nil, HandlePgErr(err)
 		}
 		tasks = append(tasks, t)
 	}
 
-	return tasks, rows.Err()
+	return tasks, HandlePgErr(rows.Err())
 }
 
 // TasksByLabel возвращает список задач с указанной меткой.
-func (s *Storage) TasksByLabel(label string) ([]Task, error) {
-	rows, err := s.db.Query(context.Background(), `
-		SELECT
-			t.id,
-			t.opened,
-			t.closed,
-			t.author_id,
-			t.assigned_id,
-			t.title,
-			t.content
-		FROM tasks t
-		INNER JOIN task_labels tl ON t.id = tl.task_id
-		INNER JOIN labels l ON tl.label_id = l.id
-		WHERE l.label = $1
+func (s *Storage) TasksByLabel(ctx context.Context, label string) ([]Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, taskSelectWithLabels+`
+		WHERE t.id IN (
+			SELECT tl.task_id
+			FROM task_labels tl
+			INNER JOIN labels l ON l.id = tl.label_id
+			WHERE l.label = $1
+		)
+		GROUP BY t.id
 		ORDER BY t.id;
 `, label)
 	if err != nil {
-		return nil, err
+		return nil, HandlePgErr(err)
 	}
 	defer rows.Close()
 
 	var tasks []Task
 	for rows.Next() {
-		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
+		t, err := scanTaskWithLabels(rows)
+		if err != nil {
+			return nil, HandlePgErr(err)
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, HandlePgErr(rows.Err())
+}
+
+// distinct возвращает labels без повторов, сохраняя порядок первого
+// появления.
+func distinct(labels []string) []string {
+	seen := make(map[string]struct{}, len(labels))
+	out := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if _, ok := seen[l]; ok {
+			continue
+		}
+		seen[l] = struct{}{}
+		out = append(out, l)
+	}
+	return out
+}
+
+// TasksByLabels возвращает список задач, помеченных указанными метками.
+// Если matchAll равен true, возвращаются только задачи, у которых
+// проставлены все переданные метки (пересечение), иначе достаточно
+// совпадения хотя бы по одной метке (объединение).
+func (s *Storage) TasksByLabels(ctx context.Context, labels []string, matchAll bool) ([]Task, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	labels = distinct(labels)
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := taskSelectWithLabels + `
+		WHERE t.id IN (
+			SELECT tl.task_id
+			FROM task_labels tl
+			INNER JOIN labels l ON l.id = tl.label_id
+			WHERE l.label = ANY($1)
+			GROUP BY tl.task_id
+	`
+	if matchAll {
+		query += `HAVING COUNT(DISTINCT l.label) = $2`
+	}
+	query += `
 		)
+		GROUP BY t.id
+		ORDER BY t.id;
+	`
+
+	var rows pgx.Rows
+	var err error
+	if matchAll {
+		rows, err = s.db.Query(ctx, query, labels, len(labels))
+	} else {
+		rows, err = s.db.Query(ctx, query, labels)
+	}
+	if err != nil {
+		return nil, HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTaskWithLabels(rows)
 		if err != nil {
-			return nil, err
+			return nil, HandlePgErr(err)
 		}
 		tasks = append(tasks, t)
 	}
 
-	return tasks, rows.Err()
+	return tasks, HandlePgErr(rows.Err())
 }
 
 // GetTaskByID возвращает задачу по её id.
-func (s *Storage) GetTaskByID(id int) (Task, error) {
-	var t Task
-	err := s.db.QueryRow(context.Background(), `
-		SELECT
-			id,
-			opened,
-			closed,
-			author_id,
-			assigned_id,
-			title,
-			content
-		FROM tasks
-		WHERE id = $1;
-`, id).Scan(
-		&t.ID,
-		&t.Opened,
-		&t.Closed,
-		&t.AuthorID,
-		&t.AssignedID,
-		&t.Title,
-		&t.Content,
-	)
+func (s *Storage) GetTaskByID(ctx context.Context, id int) (Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	row := s.db.QueryRow(ctx, taskSelectWithLabels+`
+		WHERE t.id = $1
+		GROUP BY t.id;
+`, id)
+
+	t, err := scanTaskWithLabels(row)
 	if err != nil {
-		return Task{}, err
+		return Task{}, HandlePgErr(err)
 	}
 
 	return t, nil
 }
 
 // UpdateTask обновляет задачу по её id.
-func (s *Storage) UpdateTask(id int, updatedTask Task) error {
-	_, err := s.db.Exec(context.Background(), `
+func (s *Storage) UpdateTask(ctx context.Context, id int, updatedTask Task) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
 		UPDATE tasks
 		SET
 			opened = $1,
@@ -231,19 +336,95 @@ func (s *Storage) UpdateTask(id int, updatedTask Task) error {
 		id,
 	)
 	if err != nil {
-		return err
+		return HandlePgErr(err)
 	}
 
 	return nil
 }
 
 // DeleteTaskByID удаляет задачу по её id.
-func (s *Storage) DeleteTaskByID(id int) error {
-	_, err := s.db.Exec(context.Background(), `
+func (s *Storage) DeleteTaskByID(ctx context.Context, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
 		DELETE FROM tasks WHERE id = $1;
 `, id)
 	if err != nil {
-		return err
+		return HandlePgErr(err)
+	}
+
+	return nil
+}
+
+// CreateLabel создаёт новую метку и возвращает её id.
+func (s *Storage) CreateLabel(ctx context.Context, label string) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO labels (label)
+		VALUES ($1) RETURNING id;
+		`,
+		label,
+	).Scan(&id)
+	return id, HandlePgErr(err)
+}
+
+// Labels возвращает список всех меток.
+func (s *Storage) Labels(ctx context.Context) ([]Label, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, label FROM labels ORDER BY id;
+	`)
+	if err != nil {
+		return nil, HandlePgErr(err)
+	}
+	defer rows.Close()
+
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		err = rows.Scan(&l.ID, &l.Label)
+		if err != nil {
+			return nil, HandlePgErr(err)
+		}
+		labels = append(labels, l)
+	}
+
+	return labels, HandlePgErr(rows.Err())
+}
+
+// AttachLabel привязывает метку labelID к задаче taskID.
+func (s *Storage) AttachLabel(ctx context.Context, taskID, labelID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO task_labels (task_id, label_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING;
+`, taskID, labelID)
+	if err != nil {
+		return fmt.Errorf("attach label %d to task %d: %w", labelID, taskID, HandlePgErr(err))
+	}
+
+	return nil
+}
+
+// DetachLabel отвязывает метку labelID от задачи taskID.
+func (s *Storage) DetachLabel(ctx context.Context, taskID, labelID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM task_labels WHERE task_id = $1 AND label_id = $2;
+`, taskID, labelID)
+	if err != nil {
+		return fmt.Errorf("detach label %d from task %d: %w", labelID, taskID, HandlePgErr(err))
 	}
 
 	return nil
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// maxSerializationRetries — сколько раз повторить транзакцию, упавшую
+// с ошибкой сериализации (код 40001), прежде чем вернуть её вызывающей
+// стороне.
+const maxSerializationRetries = 3
+
+// serializationRetryBackoff — базовая задержка перед повторной попыткой;
+// каждая следующая попытка ждёт вдвое дольше предыдущей.
+const serializationRetryBackoff = 50 * time.Millisecond
+
+// runSerializable выполняет fn внутри транзакции с уровнем изоляции
+// Serializable, повторяя её при ошибке сериализации (ErrSerialization)
+// с экспоненциальной задержкой между попытками.
+func (s *Storage) runSerializable(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return retryOnSerialization(ctx, func() error {
+		return s.runTx(ctx, fn)
+	})
+}
+
+// retryOnSerialization вызывает attempt, повторяя вызов при ошибке
+// сериализации (ErrSerialization) с экспоненциальной задержкой между
+// попытками. Вынесена из runSerializable отдельной функцией, не
+// зависящей от pgx.Tx, чтобы её можно было проверить в тестах без
+// подключения к БД.
+func retryOnSerialization(ctx context.Context, attempt func() error) error {
+	var err error
+	for i := 0; i <= maxSerializationRetries; i++ {
+		if i > 0 {
+			backoff := serializationRetryBackoff * time.Duration(1<<uint(i-1))
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err = attempt()
+		if err == nil || !errors.Is(err, ErrSerialization) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Storage) runTx(ctx context.Context, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		return HandlePgErr(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+			return
+		}
+		err = HandlePgErr(tx.Commit(ctx))
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		return HandlePgErr(err)
+	}
+	return nil
+}
+
+// AssignTasks назначает исполнителя assigneeID на все задачи из taskIDs
+// одной транзакцией: либо назначаются все, либо ни одна.
+func (s *Storage) AssignTasks(ctx context.Context, taskIDs []int, assigneeID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.runSerializable(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE tasks SET assigned_id = $1 WHERE id = ANY($2);
+		`, assigneeID, taskIDs)
+		return err
+	})
+}
+
+// ReassignAll переносит все задачи, назначенные на fromUserID, на
+// toUserID одной транзакцией и возвращает число переназначенных задач.
+// Используется для миграции очереди уходящего сотрудника вместо серии
+// отдельных UpdateTask, которая может завершиться частично.
+func (s *Storage) ReassignAll(ctx context.Context, fromUserID, toUserID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := s.runSerializable(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE tasks SET assigned_id = $1 WHERE assigned_id = $2;
+		`, toUserID, fromUserID)
+		if err != nil {
+			return err
+		}
+		n = int(tag.RowsAffected())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// CloseTasks закрывает все задачи из taskIDs, выставляя closedAt, одной
+// транзакцией.
+func (s *Storage) CloseTasks(ctx context.Context, taskIDs []int, closedAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.runSerializable(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE tasks SET closed = $1 WHERE id = ANY($2);
+		`, closedAt.Unix(), taskIDs)
+		return err
+	})
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryOnSerialization_succeedsAfterRetries(t *testing.T) {
+	calls := 0
+	err := retryOnSerialization(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return ErrSerialization
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retryOnSerialization() error = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("attempt() called %d times, want 3", calls)
+	}
+}
+
+func TestRetryOnSerialization_givesUpAfterMaxRetries(t *testing.T) {
+	calls := 0
+	err := retryOnSerialization(context.Background(), func() error {
+		calls++
+		return ErrSerialization
+	})
+	if !errors.Is(err, ErrSerialization) {
+		t.Fatalf("retryOnSerialization() error = %v, want ErrSerialization", err)
+	}
+	if want := maxSerializationRetries + 1; calls != want {
+		t.Errorf("attempt() called %d times, want %d", calls, want)
+	}
+}
+
+func TestRetryOnSerialization_nonSerializationErrorStopsImmediately(t *testing.T) {
+	calls := 0
+	boom := errors.New("boom")
+	err := retryOnSerialization(context.Background(), func() error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("retryOnSerialization() error = %v, want boom", err)
+	}
+	if calls != 1 {
+		t.Errorf("attempt() called %d times, want 1", calls)
+	}
+}
+
+func TestRetryOnSerialization_abortsOnContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := retryOnSerialization(ctx, func() error {
+		calls++
+		if calls == 1 {
+			cancel()
+		}
+		return ErrSerialization
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("retryOnSerialization() error = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("attempt() called %d times, want 1 (should abort during backoff)", calls)
+	}
+}
+
+func TestRetryOnSerialization_backoffIsBounded(t *testing.T) {
+	start := time.Now()
+	_ = retryOnSerialization(context.Background(), func() error {
+		return ErrSerialization
+	})
+	// maxSerializationRetries retries with doubling backoff starting at
+	// serializationRetryBackoff should comfortably finish well under a
+	// second for the configured constants.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retryOnSerialization() took %v, want it bounded by the backoff schedule", elapsed)
+	}
+}